@@ -2,11 +2,44 @@
 package runestone
 
 import (
+    "bufio"
     "bytes"
+    "context"
+    "encoding/base64"
+    "encoding/binary"
     "encoding/json"
     "fmt"
     "io"
+    "math"
+    "mime/multipart"
     "net/http"
+    "net/url"
+    "reflect"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "nhooyr.io/websocket"
+    "nhooyr.io/websocket/wsjson"
+)
+
+// chatSubprotocol is negotiated with the server when dialing a WebSocket
+// chat session, so both sides agree on the message framing below.
+const chatSubprotocol = "runestone.v1.chat"
+
+// wsPingInterval is how often a ChatSession pings the server to keep the
+// connection alive through idle proxies.
+const wsPingInterval = 30 * time.Second
+
+// defaultMaxToolIterations bounds how many tool-call round trips
+// RunConversation will make before giving up, so a model that keeps
+// requesting tools can't loop forever.
+const defaultMaxToolIterations = 8
+
+var (
+    contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+    errorType   = reflect.TypeOf((*error)(nil)).Elem()
 )
 
 // Client represents a Runestone API client
@@ -14,21 +47,578 @@ type Client struct {
     APIKey  string
     BaseURL string
     http    *http.Client
+
+    // BaseWSURL overrides the ws(s):// base URL DialChat dials. If empty
+    // (the default), DialChat derives it from BaseURL on every call, so
+    // changing BaseURL after construction is picked up automatically.
+    BaseWSURL string
+
+    transport Transport
+
+    deadline     timeoutDeadline
+    readDeadline timeoutDeadline
+}
+
+// ClientOption configures a Client constructed by NewClient.
+type ClientOption func(*Client)
+
+// WithMiddleware wraps the client's Transport with mws, in order: mws[0]
+// sees a request first and is the outermost layer, so retries configured
+// there also pass back through rate limiting or failover configured after
+// it.
+func WithMiddleware(mws ...Middleware) ClientOption {
+    return func(c *Client) {
+        for i := len(mws) - 1; i >= 0; i-- {
+            c.transport = mws[i](c.transport)
+        }
+    }
 }
 
 // NewClient creates a new Runestone client
-func NewClient(apiKey string) *Client {
-    return &Client{
-        APIKey:  apiKey,
-        BaseURL: "http://localhost:4001/v1",
-        http:    &http.Client{},
+func NewClient(apiKey string, opts ...ClientOption) *Client {
+    baseURL := "http://localhost:4001/v1"
+    httpClient := &http.Client{}
+    c := &Client{
+        APIKey:       apiKey,
+        BaseURL:      baseURL,
+        http:         httpClient,
+        transport:    &httpTransport{http: httpClient},
+        deadline:     makeTimeoutDeadline(),
+        readDeadline: makeTimeoutDeadline(),
+    }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
+}
+
+// wsURL derives a ws(s):// base URL from an http(s):// one.
+func wsURL(httpURL string) string {
+    switch {
+    case strings.HasPrefix(httpURL, "https://"):
+        return "wss://" + strings.TrimPrefix(httpURL, "https://")
+    case strings.HasPrefix(httpURL, "http://"):
+        return "ws://" + strings.TrimPrefix(httpURL, "http://")
+    default:
+        return httpURL
+    }
+}
+
+// timeoutDeadline is a mutex-protected timer that closes a cancel channel
+// when it fires, modeled on the deadline pattern used by net.Conn
+// implementations (see net/pipe.go's pipeDeadline). It lets SetDeadline be
+// called concurrently with, and reset while, a request is in flight.
+type timeoutDeadline struct {
+    mu     sync.Mutex
+    timer  *time.Timer
+    cancel chan struct{}
+}
+
+func makeTimeoutDeadline() timeoutDeadline {
+    return timeoutDeadline{cancel: make(chan struct{})}
+}
+
+func isClosedChan(c chan struct{}) bool {
+    select {
+    case <-c:
+        return true
+    default:
+        return false
+    }
+}
+
+// set arms the deadline. A zero Time clears it.
+func (d *timeoutDeadline) set(t time.Time) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    if d.timer != nil && !d.timer.Stop() {
+        <-d.cancel // wait for the in-flight fire to finish closing the channel
+    }
+    d.timer = nil
+
+    closed := isClosedChan(d.cancel)
+    if t.IsZero() {
+        if closed {
+            d.cancel = make(chan struct{})
+        }
+        return
+    }
+
+    dur := time.Until(t)
+    if dur > 0 {
+        if closed {
+            d.cancel = make(chan struct{})
+        }
+        d.timer = time.AfterFunc(dur, func() {
+            close(d.cancel)
+        })
+        return
+    }
+
+    // Deadline already in the past: fire immediately.
+    if !closed {
+        close(d.cancel)
+    }
+}
+
+func (d *timeoutDeadline) wait() <-chan struct{} {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return d.cancel
+}
+
+// SetDeadline sets the deadline for all future requests made with this
+// client, including ones already in flight. A zero value for t means
+// requests will not time out.
+func (c *Client) SetDeadline(t time.Time) error {
+    c.deadline.set(t)
+    return nil
+}
+
+// SetReadDeadline sets a second deadline for requests made with this
+// client, including ones already in flight. When it fires it cancels a
+// request at whatever stage it is in, exactly as SetDeadline does; it is
+// not limited to reading the response body. A zero value for t means
+// requests will not time out on this deadline.
+func (c *Client) SetReadDeadline(t time.Time) error {
+    c.readDeadline.set(t)
+    return nil
+}
+
+// withDeadlines returns a context that is canceled when ctx is canceled or
+// when either the client's deadline or read deadline fires, along with a
+// cancel func the caller must invoke to release the associated goroutine.
+func (c *Client) withDeadlines(ctx context.Context) (context.Context, context.CancelFunc) {
+    ctx, cancel := context.WithCancel(ctx)
+    go func() {
+        select {
+        case <-c.deadline.wait():
+        case <-c.readDeadline.wait():
+        case <-ctx.Done():
+        }
+        cancel()
+    }()
+    return ctx, cancel
+}
+
+// newRequest builds an HTTP request against c.BaseURL+path with the
+// client's context/deadline handling and Authorization header applied. The
+// returned cancel func must be called once the request (and, for a
+// streamed response, reading its body) is complete.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, context.CancelFunc, error) {
+    ctx, cancel := c.withDeadlines(ctx)
+
+    httpReq, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+    if err != nil {
+        cancel()
+        return nil, nil, err
+    }
+    httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+    return httpReq, cancel, nil
+}
+
+// send executes httpReq through the client's Transport and returns its
+// response if the request succeeded and the server responded 200 OK. The
+// caller owns resp.Body and must close it.
+func (c *Client) send(httpReq *http.Request) (*http.Response, error) {
+    resp, err := c.transport.RoundTrip(httpReq.Context(), httpReq)
+    if err != nil {
+        return nil, err
+    }
+
+    if resp.StatusCode != http.StatusOK {
+        body, _ := io.ReadAll(resp.Body)
+        resp.Body.Close()
+        return nil, fmt.Errorf("API error: %s", string(body))
+    }
+    return resp, nil
+}
+
+// sendAndDecode executes httpReq, closes its response body, and JSON-decodes
+// the body into out (out may be nil to discard it).
+func (c *Client) sendAndDecode(httpReq *http.Request, out any) error {
+    resp, err := c.send(httpReq)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// doJSON is the common request-plumbing helper shared by every JSON
+// endpoint: it marshals body (if non-nil), applies context/deadline
+// handling and standard headers, and decodes the JSON response into out.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out any) error {
+    var reader io.Reader
+    if body != nil {
+        data, err := json.Marshal(body)
+        if err != nil {
+            return err
+        }
+        reader = bytes.NewBuffer(data)
+    }
+
+    httpReq, cancel, err := c.newRequest(ctx, method, path, reader)
+    if err != nil {
+        return err
+    }
+    defer cancel()
+
+    if body != nil {
+        httpReq.Header.Set("Content-Type", "application/json")
+    }
+
+    return c.sendAndDecode(httpReq, out)
+}
+
+// Transport performs a single HTTP round trip. Implementations compose via
+// Middleware to add retries, rate limiting, and upstream failover around
+// the base httpTransport.
+type Transport interface {
+    RoundTrip(ctx context.Context, req *http.Request) (*http.Response, error)
+}
+
+// Middleware wraps a Transport with additional behavior.
+type Middleware func(Transport) Transport
+
+// httpTransport is the base Transport: it sends the request as-is over an
+// *http.Client.
+type httpTransport struct {
+    http *http.Client
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+    return t.http.Do(req.WithContext(ctx))
+}
+
+// bufferRequestBody reads and closes req.Body (if any), returning its bytes
+// so middleware can replay the request across retries or upstreams.
+func bufferRequestBody(req *http.Request) ([]byte, error) {
+    if req.Body == nil {
+        return nil, nil
+    }
+    defer req.Body.Close()
+    return io.ReadAll(req.Body)
+}
+
+// resetRequestBody rearms req.Body with a fresh reader over body.
+func resetRequestBody(req *http.Request, body []byte) {
+    if body == nil {
+        return
+    }
+    req.Body = io.NopCloser(bytes.NewReader(body))
+    req.ContentLength = int64(len(body))
+}
+
+// RetryTransport retries requests that fail with a connection error or a
+// 429/5xx response, honoring a Retry-After response header when present
+// and otherwise backing off exponentially. It only ever retries the
+// initial request; a streaming call that has already started receiving
+// its response is never replayed.
+type RetryTransport struct {
+    next       Transport
+    maxRetries int
+    baseDelay  time.Duration
+    maxDelay   time.Duration
+}
+
+// WithRetry returns a Middleware that retries a request up to maxRetries
+// times on 429/5xx responses or connection errors.
+func WithRetry(maxRetries int) Middleware {
+    return func(next Transport) Transport {
+        return &RetryTransport{
+            next:       next,
+            maxRetries: maxRetries,
+            baseDelay:  500 * time.Millisecond,
+            maxDelay:   30 * time.Second,
+        }
+    }
+}
+
+// RoundTrip implements Transport.
+func (t *RetryTransport) RoundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+    body, err := bufferRequestBody(req)
+    if err != nil {
+        return nil, err
+    }
+
+    var resp *http.Response
+    for attempt := 0; ; attempt++ {
+        resetRequestBody(req, body)
+
+        resp, err = t.next.RoundTrip(ctx, req)
+        if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+            return resp, nil
+        }
+        if attempt >= t.maxRetries {
+            return resp, err
+        }
+
+        delay := retryDelay(resp, attempt, t.baseDelay, t.maxDelay)
+        if resp != nil {
+            resp.Body.Close()
+        }
+
+        select {
+        case <-time.After(delay):
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+}
+
+// retryDelay honors a Retry-After header (seconds or HTTP-date) if present,
+// otherwise backs off exponentially from base, capped at max.
+func retryDelay(resp *http.Response, attempt int, base, max time.Duration) time.Duration {
+    if resp != nil {
+        if ra := resp.Header.Get("Retry-After"); ra != "" {
+            if secs, err := strconv.Atoi(ra); err == nil {
+                return time.Duration(secs) * time.Second
+            }
+            if when, err := http.ParseTime(ra); err == nil {
+                return time.Until(when)
+            }
+        }
+    }
+
+    delay := base * time.Duration(1<<attempt)
+    if delay > max {
+        delay = max
+    }
+    return delay
+}
+
+// tokenBucket is a simple token-bucket rate limiter for one model.
+type tokenBucket struct {
+    mu       sync.Mutex
+    tokens   float64
+    lastFill time.Time
+}
+
+func (b *tokenBucket) wait(ctx context.Context, rate float64, burst int) error {
+    for {
+        b.mu.Lock()
+        now := time.Now()
+        b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.lastFill).Seconds()*rate)
+        b.lastFill = now
+
+        if b.tokens >= 1 {
+            b.tokens--
+            b.mu.Unlock()
+            return nil
+        }
+        wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+        b.mu.Unlock()
+
+        select {
+        case <-time.After(wait):
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+}
+
+// RateLimitTransport throttles outgoing requests with an independent
+// token bucket per model, so a burst against one model doesn't starve
+// another.
+type RateLimitTransport struct {
+    next Transport
+
+    rate  float64
+    burst int
+
+    mu      sync.Mutex
+    buckets map[string]*tokenBucket
+}
+
+// WithRateLimit returns a Middleware that allows ratePerSecond requests per
+// second per model, with bursts up to burst.
+func WithRateLimit(ratePerSecond float64, burst int) Middleware {
+    return func(next Transport) Transport {
+        return &RateLimitTransport{
+            next:    next,
+            rate:    ratePerSecond,
+            burst:   burst,
+            buckets: make(map[string]*tokenBucket),
+        }
+    }
+}
+
+// RoundTrip implements Transport.
+func (t *RateLimitTransport) RoundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+    body, err := bufferRequestBody(req)
+    if err != nil {
+        return nil, err
+    }
+    resetRequestBody(req, body)
+
+    if err := t.bucketFor(modelFromRequestBody(body)).wait(ctx, t.rate, t.burst); err != nil {
+        return nil, err
+    }
+
+    resetRequestBody(req, body)
+    return t.next.RoundTrip(ctx, req)
+}
+
+func (t *RateLimitTransport) bucketFor(model string) *tokenBucket {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    b, ok := t.buckets[model]
+    if !ok {
+        b = &tokenBucket{tokens: float64(t.burst), lastFill: time.Now()}
+        t.buckets[model] = b
     }
+    return b
+}
+
+// modelFromRequestBody best-effort extracts the "model" field from a JSON
+// request body, returning "" if body isn't JSON or has none.
+func modelFromRequestBody(body []byte) string {
+    var payload struct {
+        Model string `json:"model"`
+    }
+    if err := json.Unmarshal(body, &payload); err != nil {
+        return ""
+    }
+    return payload.Model
+}
+
+// Upstream is one candidate backend for FailoverTransport.
+type Upstream struct {
+    BaseURL string
+    APIKey  string
+}
+
+// FailoverTransport rotates across an ordered list of upstreams, retrying
+// the next one on a connection error or 5xx response. It remembers the
+// last upstream that worked and tries that one first next time.
+type FailoverTransport struct {
+    next      Transport
+    upstreams []Upstream
+
+    mu      sync.Mutex
+    current int
+}
+
+// NewFailoverTransport wraps next, retrying a request against each of
+// upstreams in order (starting from whichever one last succeeded) until
+// one responds without a connection error or 5xx status.
+func NewFailoverTransport(next Transport, upstreams []Upstream) *FailoverTransport {
+    return &FailoverTransport{next: next, upstreams: upstreams}
+}
+
+// WithFailover returns a Middleware that rotates across upstreams on a
+// connection error or 5xx response, so it composes with WithRetry and
+// WithRateLimit through WithMiddleware like any other middleware.
+func WithFailover(upstreams []Upstream) Middleware {
+    return func(next Transport) Transport {
+        return NewFailoverTransport(next, upstreams)
+    }
+}
+
+// RoundTrip implements Transport.
+func (t *FailoverTransport) RoundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+    if len(t.upstreams) == 0 {
+        return t.next.RoundTrip(ctx, req)
+    }
+
+    body, err := bufferRequestBody(req)
+    if err != nil {
+        return nil, err
+    }
+
+    t.mu.Lock()
+    start := t.current
+    t.mu.Unlock()
+
+    var lastErr error
+    for i := 0; i < len(t.upstreams); i++ {
+        idx := (start + i) % len(t.upstreams)
+        upstream := t.upstreams[idx]
+
+        attemptReq := req.Clone(ctx)
+        if err := rewriteUpstream(attemptReq, upstream.BaseURL); err != nil {
+            lastErr = err
+            continue
+        }
+        if upstream.APIKey != "" {
+            attemptReq.Header.Set("Authorization", "Bearer "+upstream.APIKey)
+        }
+        resetRequestBody(attemptReq, body)
+
+        resp, err := t.next.RoundTrip(ctx, attemptReq)
+        if err == nil && resp.StatusCode < 500 {
+            t.mu.Lock()
+            t.current = idx
+            t.mu.Unlock()
+            return resp, nil
+        }
+
+        if err != nil {
+            lastErr = err
+        } else {
+            lastErr = fmt.Errorf("upstream %s: %s", upstream.BaseURL, resp.Status)
+            resp.Body.Close()
+        }
+    }
+
+    return nil, fmt.Errorf("runestone: all upstreams failed: %w", lastErr)
+}
+
+// rewriteUpstream points req at base's scheme and host, leaving the path
+// (e.g. "/v1/chat/completions") untouched on the assumption that every
+// upstream is itself an OpenAI-compatible API mounted at the same path.
+func rewriteUpstream(req *http.Request, base string) error {
+    baseURL, err := url.Parse(base)
+    if err != nil {
+        return fmt.Errorf("runestone: invalid upstream base URL %q: %w", base, err)
+    }
+
+    req.URL.Scheme = baseURL.Scheme
+    req.URL.Host = baseURL.Host
+    req.Host = baseURL.Host
+    return nil
 }
 
 // ChatMessage represents a chat message
 type ChatMessage struct {
-    Role    string `json:"role"`
-    Content string `json:"content"`
+    Role       string     `json:"role"`
+    Content    string     `json:"content,omitempty"`
+    Name       string     `json:"name,omitempty"`
+    ToolCallID string     `json:"tool_call_id,omitempty"`
+    ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall is a model-requested invocation of one of the functions
+// advertised in ChatCompletionRequest.Tools.
+type ToolCall struct {
+    ID       string `json:"id"`
+    Type     string `json:"type"`
+    Function struct {
+        Name      string `json:"name"`
+        Arguments string `json:"arguments"`
+    } `json:"function"`
+}
+
+// Tool describes a single callable function offered to the model, in the
+// shape OpenAI's tools API expects.
+type Tool struct {
+    Type     string       `json:"type"`
+    Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the JSON-schema description of a tool's name and
+// arguments, derived via reflection by ToolRegistry.Register.
+type ToolFunction struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    Parameters  json.RawMessage `json:"parameters"`
 }
 
 // ChatCompletionRequest represents a chat completion request
@@ -38,6 +628,8 @@ type ChatCompletionRequest struct {
     Temperature float64       `json:"temperature,omitempty"`
     MaxTokens   int           `json:"max_tokens,omitempty"`
     Stream      bool          `json:"stream,omitempty"`
+    Tools       []Tool        `json:"tools,omitempty"`
+    ToolChoice  string        `json:"tool_choice,omitempty"`
 }
 
 // ChatCompletionResponse represents a chat completion response
@@ -65,61 +657,633 @@ type Model struct {
     OwnedBy string `json:"owned_by"`
 }
 
-// CreateChatCompletion creates a chat completion
+// CreateChatCompletion creates a chat completion. It is equivalent to
+// calling CreateChatCompletionContext with context.Background().
 func (c *Client) CreateChatCompletion(req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+    return c.CreateChatCompletionContext(context.Background(), req)
+}
+
+// CreateChatCompletionContext creates a chat completion, aborting the
+// underlying HTTP request if ctx is canceled or either of the client's
+// deadlines fires first.
+func (c *Client) CreateChatCompletionContext(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+    var result ChatCompletionResponse
+    if err := c.doJSON(ctx, "POST", "/chat/completions", req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// ChatCompletionChunk represents one incremental update of a streamed chat
+// completion, mirroring OpenAI's delta format.
+type ChatCompletionChunk struct {
+    ID      string `json:"id"`
+    Object  string `json:"object"`
+    Created int64  `json:"created"`
+    Model   string `json:"model"`
+    Choices []struct {
+        Index int `json:"index"`
+        Delta struct {
+            Role    string `json:"role,omitempty"`
+            Content string `json:"content,omitempty"`
+        } `json:"delta"`
+        FinishReason *string `json:"finish_reason"`
+    } `json:"choices"`
+}
+
+// ChatCompletionStream reads server-sent chat completion chunks from an
+// open HTTP response. It must be closed once the caller is done with it,
+// whether or not the stream was read to completion.
+type ChatCompletionStream struct {
+    body   io.ReadCloser
+    reader *bufio.Reader
+    cancel context.CancelFunc
+}
+
+// CreateChatCompletionStream creates a chat completion with req.Stream
+// forced on and returns a ChatCompletionStream that decodes the
+// text/event-stream response as it arrives. Callers must call Close on the
+// returned stream.
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionStream, error) {
+    req.Stream = true
+
     data, err := json.Marshal(req)
     if err != nil {
         return nil, err
     }
 
-    httpReq, err := http.NewRequest("POST", c.BaseURL+"/chat/completions", bytes.NewBuffer(data))
+    httpReq, cancel, err := c.newRequest(ctx, "POST", "/chat/completions", bytes.NewBuffer(data))
     if err != nil {
         return nil, err
     }
 
-    httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
     httpReq.Header.Set("Content-Type", "application/json")
+    httpReq.Header.Set("Accept", "text/event-stream")
 
-    resp, err := c.http.Do(httpReq)
+    resp, err := c.send(httpReq)
     if err != nil {
+        cancel()
         return nil, err
     }
-    defer resp.Body.Close()
 
-    if resp.StatusCode != http.StatusOK {
-        body, _ := io.ReadAll(resp.Body)
-        return nil, fmt.Errorf("API error: %s", string(body))
+    return &ChatCompletionStream{
+        body:   resp.Body,
+        reader: bufio.NewReader(resp.Body),
+        cancel: cancel,
+    }, nil
+}
+
+// Recv returns the next chunk in the stream. It returns io.EOF once the
+// server sends the "[DONE]" sentinel or the connection is closed.
+func (s *ChatCompletionStream) Recv() (ChatCompletionChunk, error) {
+    for {
+        line, err := s.reader.ReadString('\n')
+        if err != nil {
+            if err == io.EOF {
+                return ChatCompletionChunk{}, io.EOF
+            }
+            return ChatCompletionChunk{}, err
+        }
+
+        line = strings.TrimRight(line, "\r\n")
+        if line == "" || strings.HasPrefix(line, ":") {
+            continue
+        }
+
+        data, ok := strings.CutPrefix(line, "data:")
+        if !ok {
+            continue
+        }
+        data = strings.TrimSpace(data)
+
+        if data == "[DONE]" {
+            return ChatCompletionChunk{}, io.EOF
+        }
+
+        var chunk ChatCompletionChunk
+        if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+            return ChatCompletionChunk{}, err
+        }
+        return chunk, nil
     }
+}
 
-    var result ChatCompletionResponse
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+// Close releases the underlying HTTP connection, aborting the stream if it
+// is still in progress.
+func (s *ChatCompletionStream) Close() error {
+    s.cancel()
+    return s.body.Close()
+}
+
+// ListModels lists available models. It is equivalent to calling
+// ListModelsContext with context.Background().
+func (c *Client) ListModels() ([]Model, error) {
+    return c.ListModelsContext(context.Background())
+}
+
+// ListModelsContext lists available models, aborting the underlying HTTP
+// request if ctx is canceled or either of the client's deadlines fires
+// first.
+func (c *Client) ListModelsContext(ctx context.Context) ([]Model, error) {
+    var result struct {
+        Data []Model `json:"data"`
+    }
+    if err := c.doJSON(ctx, "GET", "/models", nil, &result); err != nil {
         return nil, err
     }
+    return result.Data, nil
+}
 
+// EmbeddingRequest represents an embedding request. Input may be a string
+// or a []string to embed multiple inputs in one call.
+type EmbeddingRequest struct {
+    Model          string `json:"model"`
+    Input          any    `json:"input"`
+    EncodingFormat string `json:"encoding_format,omitempty"`
+}
+
+// Embedding is a single embedding vector. It unmarshals either the default
+// JSON array representation or, when EncodingFormat is "base64", a
+// base64-encoded little-endian float32 buffer.
+type Embedding []float32
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Embedding) UnmarshalJSON(data []byte) error {
+    data = bytes.TrimSpace(data)
+    if len(data) == 0 || data[0] != '"' {
+        var floats []float32
+        if err := json.Unmarshal(data, &floats); err != nil {
+            return err
+        }
+        *e = floats
+        return nil
+    }
+
+    var encoded string
+    if err := json.Unmarshal(data, &encoded); err != nil {
+        return err
+    }
+
+    raw, err := base64.StdEncoding.DecodeString(encoded)
+    if err != nil {
+        return fmt.Errorf("runestone: decoding base64 embedding: %w", err)
+    }
+    if len(raw)%4 != 0 {
+        return fmt.Errorf("runestone: base64 embedding has %d bytes, not a multiple of 4", len(raw))
+    }
+
+    floats := make(Embedding, len(raw)/4)
+    for i := range floats {
+        floats[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4 : i*4+4]))
+    }
+    *e = floats
+    return nil
+}
+
+// EmbeddingResponse represents an embedding response.
+type EmbeddingResponse struct {
+    Object string `json:"object"`
+    Model  string `json:"model"`
+    Data   []struct {
+        Object    string    `json:"object"`
+        Index     int       `json:"index"`
+        Embedding Embedding `json:"embedding"`
+    } `json:"data"`
+    Usage struct {
+        PromptTokens int `json:"prompt_tokens"`
+        TotalTokens  int `json:"total_tokens"`
+    } `json:"usage"`
+}
+
+// CreateEmbedding creates an embedding for req.Input.
+func (c *Client) CreateEmbedding(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error) {
+    var result EmbeddingResponse
+    if err := c.doJSON(ctx, "POST", "/embeddings", req, &result); err != nil {
+        return nil, err
+    }
     return &result, nil
 }
 
-// ListModels lists available models
-func (c *Client) ListModels() ([]Model, error) {
-    req, err := http.NewRequest("GET", c.BaseURL+"/models", nil)
+// ModerationRequest represents a moderation request. Input may be a string
+// or a []string to moderate multiple inputs in one call.
+type ModerationRequest struct {
+    Input any    `json:"input"`
+    Model string `json:"model,omitempty"`
+}
+
+// ModerationResponse represents a moderation response.
+type ModerationResponse struct {
+    ID      string `json:"id"`
+    Model   string `json:"model"`
+    Results []struct {
+        Flagged        bool               `json:"flagged"`
+        Categories     map[string]bool    `json:"categories"`
+        CategoryScores map[string]float64 `json:"category_scores"`
+    } `json:"results"`
+}
+
+// CreateModeration classifies req.Input for policy violations.
+func (c *Client) CreateModeration(ctx context.Context, req ModerationRequest) (*ModerationResponse, error) {
+    var result ModerationResponse
+    if err := c.doJSON(ctx, "POST", "/moderations", req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// TranscriptionRequest describes an audio file to transcribe. File is read
+// to completion and uploaded as multipart/form-data; it is not closed by
+// CreateTranscription.
+type TranscriptionRequest struct {
+    File     io.Reader
+    FileName string
+    Model    string
+    Language string
+}
+
+// TranscriptionResponse represents a transcription response.
+type TranscriptionResponse struct {
+    Text string `json:"text"`
+}
+
+// CreateTranscription transcribes req.File using req.Model.
+func (c *Client) CreateTranscription(ctx context.Context, req TranscriptionRequest) (*TranscriptionResponse, error) {
+    var body bytes.Buffer
+    writer := multipart.NewWriter(&body)
+
+    part, err := writer.CreateFormFile("file", req.FileName)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := io.Copy(part, req.File); err != nil {
+        return nil, err
+    }
+    if err := writer.WriteField("model", req.Model); err != nil {
+        return nil, err
+    }
+    if req.Language != "" {
+        if err := writer.WriteField("language", req.Language); err != nil {
+            return nil, err
+        }
+    }
+    if err := writer.Close(); err != nil {
+        return nil, err
+    }
+
+    httpReq, cancel, err := c.newRequest(ctx, "POST", "/audio/transcriptions", &body)
     if err != nil {
         return nil, err
     }
+    defer cancel()
+    httpReq.Header.Set("Content-Type", writer.FormDataContentType())
+
+    var result TranscriptionResponse
+    if err := c.sendAndDecode(httpReq, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// ImageRequest represents an image generation request.
+type ImageRequest struct {
+    Model  string `json:"model,omitempty"`
+    Prompt string `json:"prompt"`
+    N      int    `json:"n,omitempty"`
+    Size   string `json:"size,omitempty"`
+}
+
+// ImageResponse represents an image generation response.
+type ImageResponse struct {
+    Created int64 `json:"created"`
+    Data    []struct {
+        URL     string `json:"url,omitempty"`
+        B64JSON string `json:"b64_json,omitempty"`
+    } `json:"data"`
+}
 
-    req.Header.Set("Authorization", "Bearer "+c.APIKey)
+// CreateImage generates one or more images from req.Prompt.
+func (c *Client) CreateImage(ctx context.Context, req ImageRequest) (*ImageResponse, error) {
+    var result ImageResponse
+    if err := c.doJSON(ctx, "POST", "/images/generations", req, &result); err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
 
-    resp, err := c.http.Do(req)
+// ChatSession is a persistent, bidirectional WebSocket chat session opened
+// with DialChat. It lets callers exchange multiple messages with a model
+// without paying an HTTP round trip per message.
+type ChatSession struct {
+    conn   *websocket.Conn
+    ctx    context.Context
+    cancel context.CancelFunc
+}
+
+// DialChat opens a WebSocket chat session for the given model, negotiating
+// the runestone.v1.chat subprotocol. It dials c.BaseWSURL if set, otherwise
+// it derives a ws(s):// URL from c.BaseURL. The returned session must be
+// closed by the caller.
+func (c *Client) DialChat(ctx context.Context, model string) (*ChatSession, error) {
+    ctx, cancel := c.withDeadlines(ctx)
+
+    baseWSURL := c.BaseWSURL
+    if baseWSURL == "" {
+        baseWSURL = wsURL(c.BaseURL)
+    }
+
+    dialURL := fmt.Sprintf("%s/chat/ws?model=%s", baseWSURL, url.QueryEscape(model))
+    conn, _, err := websocket.Dial(ctx, dialURL, &websocket.DialOptions{
+        Subprotocols: []string{chatSubprotocol},
+        HTTPHeader:   http.Header{"Authorization": []string{"Bearer " + c.APIKey}},
+    })
     if err != nil {
+        cancel()
         return nil, err
     }
-    defer resp.Body.Close()
 
-    var result struct {
-        Data []Model `json:"data"`
+    sessionCtx, sessionCancel := context.WithCancel(ctx)
+    session := &ChatSession{
+        conn: conn,
+        ctx:  sessionCtx,
+        cancel: func() {
+            sessionCancel()
+            cancel()
+        },
+    }
+    go session.keepalive()
+    return session, nil
+}
+
+// keepalive pings the server at a fixed interval until the session is
+// closed, so idle connections survive proxies that drop silent sockets.
+func (s *ChatSession) keepalive() {
+    ticker := time.NewTicker(wsPingInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-s.ctx.Done():
+            return
+        case <-ticker.C:
+            pingCtx, cancel := context.WithTimeout(s.ctx, wsPingInterval/3)
+            _ = s.conn.Ping(pingCtx)
+            cancel()
+        }
     }
-    if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+}
+
+// Send writes a chat message to the session.
+func (s *ChatSession) Send(msg ChatMessage) error {
+    return wsjson.Write(s.ctx, s.conn, msg)
+}
+
+// Recv blocks until the next chat message arrives on the session.
+func (s *ChatSession) Recv() (ChatMessage, error) {
+    var msg ChatMessage
+    err := wsjson.Read(s.ctx, s.conn, &msg)
+    return msg, err
+}
+
+// Close stops the keepalive loop and closes the underlying connection.
+func (s *ChatSession) Close() error {
+    s.cancel()
+    return s.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// registeredTool is a Register'd function along with the reflective
+// information needed to decode arguments and invoke it.
+type registeredTool struct {
+    fn     reflect.Value
+    argsTy reflect.Type
+    schema ToolFunction
+}
+
+// ToolRegistry holds the set of Go functions a model is allowed to call
+// during RunConversation. The zero value is not usable; create one with
+// NewToolRegistry.
+type ToolRegistry struct {
+    mu    sync.Mutex
+    tools map[string]registeredTool
+
+    // MaxIterations bounds how many tool-call round trips RunConversation
+    // will make before returning an error. Defaults to
+    // defaultMaxToolIterations.
+    MaxIterations int
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+    return &ToolRegistry{
+        tools:         make(map[string]registeredTool),
+        MaxIterations: defaultMaxToolIterations,
+    }
+}
+
+// Register makes fn callable by the model under name. fn must have the
+// signature func(context.Context, Args) (Result, error), where Args is a
+// struct; its JSON-schema "parameters" are derived via reflection from
+// Args's exported fields and json tags.
+func (r *ToolRegistry) Register(name, description string, fn any) error {
+    fnVal := reflect.ValueOf(fn)
+    fnType := fnVal.Type()
+
+    if fnType.Kind() != reflect.Func || fnType.NumIn() != 2 || fnType.NumOut() != 2 {
+        return fmt.Errorf("runestone: tool %q must be func(context.Context, Args) (Result, error)", name)
+    }
+    if !fnType.In(0).Implements(contextType) {
+        return fmt.Errorf("runestone: tool %q: first parameter must be context.Context", name)
+    }
+    if fnType.In(1).Kind() != reflect.Struct {
+        return fmt.Errorf("runestone: tool %q: argument type must be a struct", name)
+    }
+    if !fnType.Out(1).Implements(errorType) {
+        return fmt.Errorf("runestone: tool %q: second return value must be error", name)
+    }
+
+    argsTy := fnType.In(1)
+    schema, err := schemaForStruct(argsTy)
+    if err != nil {
+        return fmt.Errorf("runestone: tool %q: %w", name, err)
+    }
+
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.tools[name] = registeredTool{
+        fn:     fnVal,
+        argsTy: argsTy,
+        schema: ToolFunction{Name: name, Description: description, Parameters: schema},
+    }
+    return nil
+}
+
+// toolList returns the OpenAI-style tools array to inject into a
+// ChatCompletionRequest.
+func (r *ToolRegistry) toolList() []Tool {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    out := make([]Tool, 0, len(r.tools))
+    for _, t := range r.tools {
+        out = append(out, Tool{Type: "function", Function: t.schema})
+    }
+    return out
+}
+
+// call decodes rawArgs into the registered tool's argument type, invokes
+// it, and returns its result ready to be marshaled back to the model.
+func (r *ToolRegistry) call(ctx context.Context, name string, rawArgs string) (any, error) {
+    r.mu.Lock()
+    tool, ok := r.tools[name]
+    r.mu.Unlock()
+    if !ok {
+        return nil, fmt.Errorf("runestone: unknown tool %q", name)
+    }
+
+    args := reflect.New(tool.argsTy)
+    if rawArgs != "" {
+        if err := json.Unmarshal([]byte(rawArgs), args.Interface()); err != nil {
+            return nil, fmt.Errorf("runestone: decoding arguments for tool %q: %w", name, err)
+        }
+    }
+
+    out := tool.fn.Call([]reflect.Value{reflect.ValueOf(ctx), args.Elem()})
+    if err, _ := out[1].Interface().(error); err != nil {
         return nil, err
     }
+    return out[0].Interface(), nil
+}
 
-    return result.Data, nil
+// schemaForStruct derives a JSON-schema "object" description from a Go
+// struct's exported fields and json tags.
+func schemaForStruct(t reflect.Type) (json.RawMessage, error) {
+    properties := make(map[string]any, t.NumField())
+    var required []string
+
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if field.PkgPath != "" {
+            continue
+        }
+
+        name, omitempty := jsonFieldName(field)
+        if name == "-" {
+            continue
+        }
+
+        properties[name] = jsonSchemaType(field.Type)
+        if !omitempty {
+            required = append(required, name)
+        }
+    }
+
+    schema := map[string]any{
+        "type":       "object",
+        "properties": properties,
+    }
+    if len(required) > 0 {
+        schema["required"] = required
+    }
+
+    return json.Marshal(schema)
+}
+
+// jsonFieldName reports the JSON name a struct field encodes as, and
+// whether it is marked omitempty, following encoding/json's own rules.
+func jsonFieldName(field reflect.StructField) (name string, omitempty bool) {
+    name = field.Name
+    tag := field.Tag.Get("json")
+    if tag == "" {
+        return name, false
+    }
+
+    parts := strings.Split(tag, ",")
+    if parts[0] != "" {
+        name = parts[0]
+    }
+    for _, opt := range parts[1:] {
+        if opt == "omitempty" {
+            omitempty = true
+        }
+    }
+    return name, omitempty
+}
+
+// jsonSchemaType maps a Go type to its JSON-schema type description.
+func jsonSchemaType(t reflect.Type) map[string]any {
+    switch t.Kind() {
+    case reflect.Ptr:
+        return jsonSchemaType(t.Elem())
+    case reflect.String:
+        return map[string]any{"type": "string"}
+    case reflect.Bool:
+        return map[string]any{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return map[string]any{"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+        return map[string]any{"type": "number"}
+    case reflect.Slice, reflect.Array:
+        return map[string]any{"type": "array", "items": jsonSchemaType(t.Elem())}
+    case reflect.Struct:
+        properties := make(map[string]any, t.NumField())
+        for i := 0; i < t.NumField(); i++ {
+            field := t.Field(i)
+            if field.PkgPath != "" {
+                continue
+            }
+            name, _ := jsonFieldName(field)
+            properties[name] = jsonSchemaType(field.Type)
+        }
+        return map[string]any{"type": "object", "properties": properties}
+    default:
+        return map[string]any{}
+    }
+}
+
+// RunConversation drives req through the model, automatically satisfying
+// any tool_calls the model returns using registry and re-issuing the
+// completion, until the model replies with a plain assistant message or
+// registry.MaxIterations round trips are exhausted.
+func (c *Client) RunConversation(ctx context.Context, req ChatCompletionRequest, registry *ToolRegistry) (*ChatCompletionResponse, error) {
+    req.Tools = registry.toolList()
+
+    maxIterations := registry.MaxIterations
+    if maxIterations <= 0 {
+        maxIterations = defaultMaxToolIterations
+    }
+
+    for i := 0; i < maxIterations; i++ {
+        resp, err := c.CreateChatCompletionContext(ctx, req)
+        if err != nil {
+            return nil, err
+        }
+        if len(resp.Choices) == 0 || len(resp.Choices[0].Message.ToolCalls) == 0 {
+            return resp, nil
+        }
+
+        message := resp.Choices[0].Message
+        req.Messages = append(req.Messages, message)
+
+        for _, toolCall := range message.ToolCalls {
+            result, callErr := registry.call(ctx, toolCall.Function.Name, toolCall.Function.Arguments)
+
+            var content []byte
+            if callErr != nil {
+                content, err = json.Marshal(map[string]string{"error": callErr.Error()})
+            } else {
+                content, err = json.Marshal(result)
+            }
+            if err != nil {
+                return nil, fmt.Errorf("runestone: marshaling result of tool %q: %w", toolCall.Function.Name, err)
+            }
+
+            req.Messages = append(req.Messages, ChatMessage{
+                Role:       "tool",
+                Content:    string(content),
+                ToolCallID: toolCall.ID,
+            })
+        }
+    }
+
+    return nil, fmt.Errorf("runestone: exceeded max tool iterations (%d)", maxIterations)
 }
@@ -0,0 +1,186 @@
+package runestone
+
+import (
+    "bufio"
+    "encoding/base64"
+    "encoding/binary"
+    "io"
+    "math"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestTimeoutDeadline(t *testing.T) {
+    cases := []struct {
+        name string
+        run  func(t *testing.T)
+    }{
+        {
+            name: "reset while pending pushes out the fire time",
+            run: func(t *testing.T) {
+                d := makeTimeoutDeadline()
+                d.set(time.Now().Add(30 * time.Millisecond))
+                d.set(time.Now().Add(150 * time.Millisecond))
+
+                select {
+                case <-d.wait():
+                    t.Fatal("fired before the reset deadline elapsed")
+                case <-time.After(80 * time.Millisecond):
+                }
+
+                select {
+                case <-d.wait():
+                case <-time.After(200 * time.Millisecond):
+                    t.Fatal("never fired after the reset deadline elapsed")
+                }
+            },
+        },
+        {
+            name: "zero time clears a pending deadline",
+            run: func(t *testing.T) {
+                d := makeTimeoutDeadline()
+                d.set(time.Now().Add(30 * time.Millisecond))
+                d.set(time.Time{})
+
+                select {
+                case <-d.wait():
+                    t.Fatal("fired despite being cleared")
+                case <-time.After(80 * time.Millisecond):
+                }
+            },
+        },
+        {
+            name: "past deadline fires immediately",
+            run: func(t *testing.T) {
+                d := makeTimeoutDeadline()
+                d.set(time.Now().Add(-time.Second))
+
+                select {
+                case <-d.wait():
+                case <-time.After(50 * time.Millisecond):
+                    t.Fatal("did not fire for an already-past deadline")
+                }
+            },
+        },
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, tc.run)
+    }
+}
+
+func TestChatCompletionStreamRecv(t *testing.T) {
+    cases := []struct {
+        name string
+        raw  string
+        want []string
+    }{
+        {
+            name: "delta content chunks, comment ignored, DONE sentinel ends the stream",
+            raw: "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+                ": keep-alive\n" +
+                "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"}}]}\n\n" +
+                "data: [DONE]\n\n",
+            want: []string{"Hel", "lo"},
+        },
+        {
+            name: "connection closing without a DONE sentinel also ends the stream",
+            raw:  "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n",
+            want: []string{"hi"},
+        },
+        {
+            name: "empty stream ends immediately",
+            raw:  "",
+            want: nil,
+        },
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            stream := &ChatCompletionStream{
+                reader: bufio.NewReader(strings.NewReader(tc.raw)),
+                body:   io.NopCloser(strings.NewReader(tc.raw)),
+                cancel: func() {},
+            }
+
+            var got []string
+            for {
+                chunk, err := stream.Recv()
+                if err == io.EOF {
+                    break
+                }
+                if err != nil {
+                    t.Fatalf("Recv: %v", err)
+                }
+                if len(chunk.Choices) == 0 {
+                    t.Fatalf("chunk has no choices: %+v", chunk)
+                }
+                got = append(got, chunk.Choices[0].Delta.Content)
+            }
+
+            if !reflect.DeepEqual(got, tc.want) {
+                t.Errorf("got %v, want %v", got, tc.want)
+            }
+        })
+    }
+}
+
+func encodeBase64Embedding(values []float32) string {
+    buf := make([]byte, 4*len(values))
+    for i, v := range values {
+        binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+    }
+    return base64.StdEncoding.EncodeToString(buf)
+}
+
+func TestEmbeddingUnmarshalJSON(t *testing.T) {
+    cases := []struct {
+        name    string
+        json    string
+        want    Embedding
+        wantErr bool
+    }{
+        {
+            name: "plain JSON number array",
+            json: `[0.5, -1.25, 2]`,
+            want: Embedding{0.5, -1.25, 2},
+        },
+        {
+            name: "base64-encoded little-endian float32 buffer",
+            json: `"` + encodeBase64Embedding([]float32{0.5, -1.25, 2}) + `"`,
+            want: Embedding{0.5, -1.25, 2},
+        },
+        {
+            name:    "base64 buffer not a multiple of 4 bytes is an error",
+            json:    `"` + base64.StdEncoding.EncodeToString([]byte{1, 2, 3}) + `"`,
+            wantErr: true,
+        },
+        {
+            name:    "invalid base64 is an error",
+            json:    `"not valid base64!!"`,
+            wantErr: true,
+        },
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            var got Embedding
+            err := got.UnmarshalJSON([]byte(tc.json))
+
+            if tc.wantErr {
+                if err == nil {
+                    t.Fatal("expected an error, got nil")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("UnmarshalJSON: %v", err)
+            }
+            if !reflect.DeepEqual(got, tc.want) {
+                t.Errorf("got %v, want %v", got, tc.want)
+            }
+        })
+    }
+}